@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultBufSize is Conn's default read/write buffer size: big enough that
+// a run of small length-prefixed messages coalesces into a handful of
+// syscalls instead of three (one write, two reads) per message.
+const defaultBufSize = 64 * 1024
+
+// connConfig holds NewConn's configurable fields; Option funcs mutate it
+// before Conn is built.
+type connConfig struct {
+	bufSize int
+}
+
+// Option configures a Conn created by NewConn.
+type Option func(*connConfig)
+
+// WithBufferSize overrides the default ~64KB read/write buffer size.
+func WithBufferSize(n int) Option {
+	return func(c *connConfig) { c.bufSize = n }
+}
+
+// Conn wraps a raw fd with buffered reads and writes so many small
+// length-prefixed messages coalesce into far fewer syscalls than the
+// original query()'s one syscall.Write and two syscall.Reads (header,
+// then body) per message.
+//
+// Conn keeps the *os.File os.NewFile wrapped fd in, rather than just the
+// bare fd: os.NewFile registers a GC finalizer that closes the fd once
+// the *os.File becomes unreachable, so letting it go out of scope would
+// leave the fd closed out from under Conn at an unpredictable time, by
+// which point the OS may already have handed the same fd number to an
+// unrelated connection. Routing Close through f.Close() disarms that
+// finalizer instead of racing it with a raw syscall.Close.
+//
+// A Conn returned by a Pool's Get remembers that pool; Close on it
+// returns the Conn to the pool instead of closing its file. A Conn
+// created directly via NewConn has no pool and Close just closes it.
+type Conn struct {
+	f    *os.File
+	r    *bufio.Reader
+	w    *bufio.Writer
+	pool *Pool
+}
+
+// NewConn wraps fd, an already-connected socket, in a Conn.
+func NewConn(fd int, opts ...Option) *Conn {
+	cfg := connConfig{bufSize: defaultBufSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	f := os.NewFile(uintptr(fd), "conn")
+	return &Conn{
+		f: f,
+		r: bufio.NewReaderSize(f, cfg.bufSize),
+		w: bufio.NewWriterSize(f, cfg.bufSize),
+	}
+}
+
+// Close releases the Conn: if it came from a Pool, it's returned there
+// for reuse; otherwise its underlying file is closed directly.
+func (c *Conn) Close() error {
+	if c.pool != nil {
+		return c.pool.put(c)
+	}
+	return c.f.Close()
+}
+
+// ReadFrame reads one length-prefixed frame: a 4-byte little-endian length
+// header followed by that many bytes of payload.
+func (c *Conn) ReadFrame() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, fmt.Errorf("read frame header: %v", err)
+	}
+
+	length := binary.LittleEndian.Uint32(header[:])
+	if length > kMaxMsg {
+		return nil, fmt.Errorf("frame too long: %d", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return nil, fmt.Errorf("read frame body: %v", err)
+	}
+	return payload, nil
+}
+
+// WriteFrame buffers one length-prefixed frame for payload. Call Flush to
+// actually send it, and anything else buffered, over the wire.
+func (c *Conn) WriteFrame(payload []byte) error {
+	if len(payload) > kMaxMsg {
+		return fmt.Errorf("message too long")
+	}
+
+	var header [4]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := c.w.Write(header[:]); err != nil {
+		return fmt.Errorf("write frame header: %v", err)
+	}
+	if _, err := c.w.Write(payload); err != nil {
+		return fmt.Errorf("write frame body: %v", err)
+	}
+	return nil
+}
+
+// Flush sends every frame buffered by WriteFrame since the last Flush.
+func (c *Conn) Flush() error {
+	return c.w.Flush()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+
+	"redis-scratch/netpoll"
+)
+
+// eventLoopTickMs bounds how long Run's poller.Wait can block, so Stop is
+// noticed promptly instead of only once the next fd becomes ready.
+const eventLoopTickMs = 100
+
+// ConnHandler receives the frames and close notifications EventLoop
+// produces for one registered fd.
+type ConnHandler struct {
+	// OnFrame is called with each complete length-prefixed frame's
+	// payload, in arrival order.
+	OnFrame func(fd int, payload []byte)
+	// OnClose is called once the fd is removed from the loop and
+	// closed, either because the peer went away or a protocol error
+	// occurred. err describes which, and is never nil.
+	OnClose func(fd int, err error)
+}
+
+// eventConn tracks one registered fd's handler and its not-yet-complete
+// read buffer.
+type eventConn struct {
+	handler ConnHandler
+	rbuf    []byte
+}
+
+// EventLoop multiplexes many non-blocking client connections on a single
+// goroutine, parsing query()'s length-prefixed framing incrementally so a
+// slow or idle peer never blocks progress on the rest.
+type EventLoop struct {
+	poller netpoll.Poller
+	conns  map[int]*eventConn
+	stop   chan struct{}
+}
+
+// NewEventLoop creates an EventLoop with no registered connections.
+func NewEventLoop() (*EventLoop, error) {
+	poller, err := netpoll.New()
+	if err != nil {
+		return nil, fmt.Errorf("netpoll.New: %v", err)
+	}
+	return &EventLoop{
+		poller: poller,
+		conns:  make(map[int]*eventConn),
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// Register sets fd non-blocking and adds it to the loop. Once Run is
+// running, handler.OnFrame fires for each complete frame read off fd.
+func (l *EventLoop) Register(fd int, handler ConnHandler) error {
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return fmt.Errorf("set nonblocking: %v", err)
+	}
+	if err := l.poller.Add(fd, false); err != nil {
+		return fmt.Errorf("register fd: %v", err)
+	}
+	l.conns[fd] = &eventConn{handler: handler}
+	return nil
+}
+
+// Run polls for readiness until Stop is called, reading whatever is
+// available on each ready fd and dispatching every frame that's fully
+// arrived. A read returning EAGAIN just means "nothing more right now"
+// and sends the loop back to poller.Wait rather than blocking.
+func (l *EventLoop) Run() error {
+	events := make([]netpoll.Event, 128)
+
+	for {
+		select {
+		case <-l.stop:
+			return nil
+		default:
+		}
+
+		n, err := l.poller.Wait(events, eventLoopTickMs)
+		if err != nil {
+			return fmt.Errorf("poller wait: %v", err)
+		}
+
+		for i := 0; i < n; i++ {
+			ev := events[i]
+			conn, ok := l.conns[ev.Fd]
+			if !ok {
+				continue
+			}
+
+			if ev.Err {
+				l.closeConn(ev.Fd, conn, fmt.Errorf("connection error"))
+				continue
+			}
+			if ev.Readable {
+				l.readConn(ev.Fd, conn)
+			}
+		}
+	}
+}
+
+// Stop tells Run to return at its next poll tick.
+func (l *EventLoop) Stop() {
+	close(l.stop)
+}
+
+// readConn drains every byte currently available on fd into conn's read
+// buffer, stopping at EAGAIN, then dispatches whatever full frames that
+// leaves.
+func (l *EventLoop) readConn(fd int, conn *eventConn) {
+	tmp := make([]byte, 4096)
+	for {
+		n, err := syscall.Read(fd, tmp)
+		if err != nil {
+			if err == syscall.EAGAIN {
+				break
+			}
+			l.closeConn(fd, conn, err)
+			return
+		}
+		if n == 0 {
+			l.closeConn(fd, conn, fmt.Errorf("EOF reading from socket"))
+			return
+		}
+		conn.rbuf = append(conn.rbuf, tmp[:n]...)
+	}
+
+	l.dispatchFrames(fd, conn)
+}
+
+// dispatchFrames pulls every complete length-prefixed frame (4-byte
+// little-endian length header plus that many bytes of payload) out of
+// conn.rbuf and hands each to the handler, in order, leaving any trailing
+// partial frame buffered for the next read.
+func (l *EventLoop) dispatchFrames(fd int, conn *eventConn) {
+	for {
+		if len(conn.rbuf) < 4 {
+			return
+		}
+
+		length := binary.LittleEndian.Uint32(conn.rbuf[:4])
+		if length > kMaxMsg {
+			l.closeConn(fd, conn, fmt.Errorf("frame too long: %d", length))
+			return
+		}
+		if uint32(len(conn.rbuf)) < 4+length {
+			return
+		}
+
+		payload := conn.rbuf[4 : 4+length]
+		conn.rbuf = conn.rbuf[4+length:]
+		if conn.handler.OnFrame != nil {
+			conn.handler.OnFrame(fd, payload)
+		}
+	}
+}
+
+// closeConn unregisters and closes fd and notifies its handler.
+func (l *EventLoop) closeConn(fd int, conn *eventConn, err error) {
+	l.poller.Remove(fd)
+	delete(l.conns, fd)
+	syscall.Close(fd)
+	if conn.handler.OnClose != nil {
+		conn.handler.OnClose(fd, err)
+	}
+}
+
+// DialNonblocking creates a non-blocking TCP socket connected to addr,
+// suitable for registering with an EventLoop. It asks for SOCK_NONBLOCK
+// and SOCK_CLOEXEC at socket(2) time to save the extra fcntl calls
+// SetNonblock and CloseOnExec would otherwise need, falling back to a
+// plain socket plus those calls on kernels that reject the combined
+// flags with EINVAL.
+func DialNonblocking(addr syscall.Sockaddr) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, syscall.IPPROTO_TCP)
+	if err == syscall.EINVAL {
+		fd, err = syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+		if err == nil {
+			syscall.CloseOnExec(fd)
+			if err = syscall.SetNonblock(fd, true); err != nil {
+				syscall.Close(fd)
+			}
+		}
+	}
+	if err != nil {
+		return -1, fmt.Errorf("socket: %v", err)
+	}
+
+	if err := syscall.Connect(fd, addr); err != nil && err != syscall.EINPROGRESS {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("connect: %v", err)
+	}
+
+	return fd, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ClientConfig describes how a Pool dials and times out connections to a
+// single server address.
+type ClientConfig struct {
+	// Addr is the server address, "host:port", passed to resolveSockaddr.
+	Addr string
+	// DialTimeout bounds how long connecting a new fd may take. Zero
+	// means no timeout (a blocking connect).
+	DialTimeout time.Duration
+	// ReadTimeout and WriteTimeout set SO_RCVTIMEO/SO_SNDTIMEO on every
+	// dialed fd, so a hung server makes read_full/write_full return an
+	// error instead of blocking the caller forever. Zero means no
+	// timeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxIdle caps how many idle connections Pool keeps around; beyond
+	// that, a returned Conn is just closed. Zero means unbounded.
+	MaxIdle int
+	// IdleTimeout reaps idle connections that have sat unused longer
+	// than this. Zero means idle connections are never reaped by age.
+	IdleTimeout time.Duration
+}
+
+// idleConn is one connection sitting in Pool's idle list, along with the
+// time it was returned there.
+type idleConn struct {
+	conn     *Conn
+	returnAt time.Time
+}
+
+// Pool hands out pooled *Conn connections to cfg.Addr, modeled on the
+// connection-pool pattern redigo uses: Get returns an idle connection if
+// one is available and still fresh, otherwise dials a new one; Conn.Close
+// returns it to the pool instead of tearing down the fd.
+type Pool struct {
+	cfg ClientConfig
+
+	mu   sync.Mutex
+	idle []*idleConn
+}
+
+// NewPool creates a Pool that dials cfg.Addr on demand.
+func NewPool(cfg ClientConfig) *Pool {
+	return &Pool{cfg: cfg}
+}
+
+// Get returns a pooled connection: an idle one if Pool has a fresh one
+// ready, otherwise a freshly dialed one. Callers must return it via
+// Conn.Close when done.
+func (p *Pool) Get() (*Conn, error) {
+	p.mu.Lock()
+	p.reapLocked()
+	if n := len(p.idle); n > 0 {
+		ic := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return ic.conn, nil
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	conn.pool = p
+	return conn, nil
+}
+
+// put returns conn to the idle list, or closes it outright if the pool is
+// already at MaxIdle. It's called by Conn.Close, never directly.
+func (p *Pool) put(conn *Conn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cfg.MaxIdle > 0 && len(p.idle) >= p.cfg.MaxIdle {
+		return conn.f.Close()
+	}
+
+	p.idle = append(p.idle, &idleConn{conn: conn, returnAt: time.Now()})
+	return nil
+}
+
+// reapLocked drops and closes idle connections older than IdleTimeout.
+// Callers must hold p.mu.
+func (p *Pool) reapLocked() {
+	if p.cfg.IdleTimeout <= 0 || len(p.idle) == 0 {
+		return
+	}
+
+	now := time.Now()
+	live := p.idle[:0]
+	for _, ic := range p.idle {
+		if now.Sub(ic.returnAt) > p.cfg.IdleTimeout {
+			ic.conn.f.Close()
+			continue
+		}
+		live = append(live, ic)
+	}
+	p.idle = live
+}
+
+// dial connects a fresh fd to p.cfg.Addr, applying DialTimeout and the
+// read/write socket timeouts, and wraps it in a Conn.
+func (p *Pool) dial() (*Conn, error) {
+	sa, err := resolveSockaddr(p.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := dialWithTimeout(sa, p.cfg.DialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %v", p.cfg.Addr, err)
+	}
+
+	if err := setSockTimeouts(fd, p.cfg.ReadTimeout, p.cfg.WriteTimeout); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return NewConn(fd), nil
+}
+
+// dialWithTimeout connects a new TCP socket to sa, bounding the connect
+// itself by timeout (zero means block indefinitely). It does this by
+// making the socket non-blocking for the connect, waiting for it to
+// become writable via select(2), and checking SO_ERROR for the outcome,
+// then restores blocking mode for the caller.
+func dialWithTimeout(sa syscall.Sockaddr, timeout time.Duration) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	if err != nil {
+		return -1, fmt.Errorf("socket: %v", err)
+	}
+
+	if timeout <= 0 {
+		if err := syscall.Connect(fd, sa); err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("connect: %v", err)
+		}
+		return fd, nil
+	}
+
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("set nonblocking: %v", err)
+	}
+
+	err = syscall.Connect(fd, sa)
+	if err != nil && err != syscall.EINPROGRESS {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("connect: %v", err)
+	}
+
+	if err == syscall.EINPROGRESS {
+		var wfds syscall.FdSet
+		wfds.Bits[fd/64] |= 1 << (uint(fd) % 64)
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+
+		n, err := syscall.Select(fd+1, nil, &wfds, nil, &tv)
+		if err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("select: %v", err)
+		}
+		if n == 0 {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("connect timed out after %v", timeout)
+		}
+
+		errno, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+		if err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("getsockopt SO_ERROR: %v", err)
+		}
+		if errno != 0 {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("connect: %v", syscall.Errno(errno))
+		}
+	}
+
+	if err := syscall.SetNonblock(fd, false); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("clear nonblocking: %v", err)
+	}
+	return fd, nil
+}
+
+// setSockTimeouts sets SO_RCVTIMEO/SO_SNDTIMEO on fd so blocking
+// read_full/write_full calls over it return an error instead of hanging
+// on an unresponsive peer. A zero duration leaves the corresponding
+// timeout unset (block indefinitely).
+func setSockTimeouts(fd int, readTimeout, writeTimeout time.Duration) error {
+	if readTimeout > 0 {
+		tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+		if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+			return fmt.Errorf("set SO_RCVTIMEO: %v", err)
+		}
+	}
+	if writeTimeout > 0 {
+		tv := syscall.NsecToTimeval(writeTimeout.Nanoseconds())
+		if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_SNDTIMEO, &tv); err != nil {
+			return fmt.Errorf("set SO_SNDTIMEO: %v", err)
+		}
+	}
+	return nil
+}
@@ -3,16 +3,26 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"syscall"
 )
 
 const kMaxMsg = 4096
 
-// read_full ensures that exactly 'len' bytes are read from the file descriptor
+// fdConn adapts a raw fd to io.Reader/io.Writer, without taking ownership
+// of it, so the generalized read_full/write_full below can drive the
+// length-prefixed framing over a plain socket the same way they drive it
+// over a *tls.Conn in Dial/Query.
+type fdConn int
+
+func (fd fdConn) Read(p []byte) (int, error)  { return syscall.Read(int(fd), p) }
+func (fd fdConn) Write(p []byte) (int, error) { return syscall.Write(int(fd), p) }
+
+// read_full ensures that exactly 'len' bytes are read from r
 // This function handles partial reads by continuing to read until all requested bytes are received
 //
 // Parameters:
-//   - fd (int): File descriptor to read from
+//   - r (io.Reader): source to read from (a raw fd via fdConn, or a *tls.Conn)
 //   - buf ([]byte): Buffer to store the read data
 //   - len (int): Number of bytes to read
 //
@@ -21,13 +31,13 @@ const kMaxMsg = 4096
 //
 // Example usage:
 //   buffer := make([]byte, 1024)
-//   err := read_full(connfd, buffer, 512)  // Read exactly 512 bytes
-func read_full(fd int, buf []byte, len int) error {
-	
+//   err := read_full(fdConn(connfd), buffer, 512)  // Read exactly 512 bytes
+func read_full(r io.Reader, buf []byte, len int) error {
+
 	offset:=0
 
 	for len > 0 {
-		n, err:= syscall.Read(fd, buf[offset:len])
+		n, err:= r.Read(buf[offset:len])
 
 		if err != nil {
 			return fmt.Errorf("Error reading from socket: %v", err)
@@ -44,11 +54,11 @@ func read_full(fd int, buf []byte, len int) error {
 	return nil
 }
 
-// write_full ensures that all bytes in the buffer are written to the file descriptor
+// write_full ensures that all bytes in the buffer are written to w
 // This function handles partial writes by continuing to write until all data is sent
 //
 // Parameters:
-//   - fd (int): File descriptor to write to
+//   - w (io.Writer): destination to write to (a raw fd via fdConn, or a *tls.Conn)
 //   - buf ([]byte): Buffer containing data to write
 //
 // Returns:
@@ -56,14 +66,14 @@ func read_full(fd int, buf []byte, len int) error {
 //
 // Example usage:
 //   message := []byte("PING")
-//   err := write_full(connfd, message)  // Write entire message
-func write_full(fd int, buf []byte) error{
+//   err := write_full(fdConn(connfd), message)  // Write entire message
+func write_full(w io.Writer, buf []byte) error{
 	total:=len(buf)
 	offset:=0
 
 	for total > 0 {
-		n, err:= syscall.Write(fd,buf[offset:total])
-	
+		n, err:= w.Write(buf[offset:total])
+
 		if err!=nil{
 			return fmt.Errorf("Error writing to socket: %v", err)
 		}
@@ -109,8 +119,8 @@ func query(fd int, text string) error {
 	copy(wbuf[4:], text)
 
 	// Write full request
-	err := write_full(fd, wbuf); 
-	
+	err := write_full(fdConn(fd), wbuf);
+
 	if err != nil {
 		return fmt.Errorf("write_all error: %v", err)
 	}
@@ -118,8 +128,8 @@ func query(fd int, text string) error {
 	// Read 4-byte response header
 	rbuf := make([]byte, 4+kMaxMsg+1)
 
-	err = read_full(fd, rbuf[:4],4); 
-	
+	err = read_full(fdConn(fd), rbuf[:4],4);
+
 	if err != nil {
 		return err
 	}
@@ -132,7 +142,7 @@ func query(fd int, text string) error {
 	}
 
 	// Read response body
-	err = read_full(fd, rbuf[4:4+replyLen],int(replyLen)); 
+	err = read_full(fdConn(fd), rbuf[4:4+replyLen],int(replyLen));
 	
 	if err != nil {
 		fmt.Println("read() error:", err)
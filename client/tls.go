@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// dialConfig holds Dial's configurable fields; DialOption funcs mutate it
+// before the connection is established.
+type dialConfig struct {
+	tlsConfig *tls.Config
+}
+
+// DialOption configures a connection created by Dial.
+type DialOption func(*dialConfig)
+
+// WithTLS wraps the TCP connection in a TLS client handshake using cfg
+// before any framing is exchanged, for talking to a Redis server (or this
+// toy server) exposed over the network instead of a trusted localhost
+// socket.
+func WithTLS(cfg *tls.Config) DialOption {
+	return func(c *dialConfig) { c.tlsConfig = cfg }
+}
+
+// QueryConn is a connected endpoint speaking query()'s length-prefixed
+// framing, optionally over TLS, via Query.
+type QueryConn struct {
+	rw     io.ReadWriter
+	closer io.Closer
+}
+
+// Dial connects to addr ("host:port") and returns a QueryConn ready for
+// Query calls. The raw fd is created and connected with syscalls, same as
+// the rest of this package, then handed to net.FileConn so WithTLS can
+// wrap it in a *tls.Conn; without WithTLS, Query runs directly over the
+// plain TCP connection.
+func Dial(addr string, opts ...DialOption) (*QueryConn, error) {
+	var cfg dialConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	sa, err := resolveSockaddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, fmt.Errorf("socket: %v", err)
+	}
+	if err := syscall.Connect(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("connect: %v", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "conn")
+	netConn, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("file conn: %v", err)
+	}
+
+	var conn io.ReadWriteCloser = netConn
+	if cfg.tlsConfig != nil {
+		tlsConn := tls.Client(netConn, cfg.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("tls handshake: %v", err)
+		}
+		conn = tlsConn
+	}
+
+	return &QueryConn{rw: conn, closer: conn}, nil
+}
+
+// Query sends text as a length-prefixed frame and returns the server's
+// reply body. It's query()'s protocol generalized over any
+// io.ReadWriter, so the same framing runs over TLS as over a raw fd.
+func (c *QueryConn) Query(text string) (string, error) {
+	length := len(text)
+	if length > kMaxMsg {
+		return "", fmt.Errorf("message too long")
+	}
+
+	wbuf := make([]byte, 4+length)
+	binary.LittleEndian.PutUint32(wbuf[:4], uint32(length))
+	copy(wbuf[4:], text)
+	if err := write_full(c.rw, wbuf); err != nil {
+		return "", fmt.Errorf("write_all error: %v", err)
+	}
+
+	rbuf := make([]byte, 4+kMaxMsg+1)
+	if err := read_full(c.rw, rbuf[:4], 4); err != nil {
+		return "", err
+	}
+
+	replyLen := binary.LittleEndian.Uint32(rbuf[:4])
+	if replyLen > kMaxMsg {
+		return "", fmt.Errorf("response too long: %d", replyLen)
+	}
+
+	if err := read_full(c.rw, rbuf[4:4+replyLen], int(replyLen)); err != nil {
+		return "", err
+	}
+
+	return string(rbuf[4 : 4+replyLen]), nil
+}
+
+// Close closes the underlying connection.
+func (c *QueryConn) Close() error {
+	return c.closer.Close()
+}
+
+// resolveSockaddr parses "host:port" into the IPv4 sockaddr syscall.Connect
+// expects, resolving host if it isn't already a literal IP.
+func resolveSockaddr(addr string) (syscall.Sockaddr, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %v", portStr, err)
+	}
+
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %q: %v", host, err)
+		}
+		for _, candidate := range ips {
+			if v4 := candidate.To4(); v4 != nil {
+				ip = v4
+				break
+			}
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("no IPv4 address found for %q", host)
+		}
+	}
+
+	sa := &syscall.SockaddrInet4{Port: port}
+	copy(sa.Addr[:], ip)
+	return sa, nil
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package netpoll
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// epollPoller implements Poller on Linux using epoll(7) via
+// EpollCreate1/EpollCtl/EpollWait.
+type epollPoller struct {
+	epfd int
+}
+
+func newPoller() (Poller, error) {
+	epfd, err := syscall.EpollCreate1(0)
+	if err != nil {
+		return nil, fmt.Errorf("epoll_create1: %v", err)
+	}
+	return &epollPoller{epfd: epfd}, nil
+}
+
+func interestMask(writable bool) uint32 {
+	mask := uint32(syscall.EPOLLIN)
+	if writable {
+		mask |= syscall.EPOLLOUT
+	}
+	return mask
+}
+
+func (p *epollPoller) Add(fd int, writable bool) error {
+	ev := syscall.EpollEvent{Events: interestMask(writable), Fd: int32(fd)}
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, fd, &ev)
+}
+
+func (p *epollPoller) Modify(fd int, writable bool) error {
+	ev := syscall.EpollEvent{Events: interestMask(writable), Fd: int32(fd)}
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_MOD, fd, &ev)
+}
+
+func (p *epollPoller) Remove(fd int) error {
+	return syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+func (p *epollPoller) Wait(events []Event, timeoutMs int) (int, error) {
+	raw := make([]syscall.EpollEvent, len(events))
+	n, err := syscall.EpollWait(p.epfd, raw, timeoutMs)
+	if err != nil {
+		if err == syscall.EINTR {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("epoll_wait: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		events[i] = Event{
+			Fd:       int(raw[i].Fd),
+			Readable: raw[i].Events&syscall.EPOLLIN != 0,
+			Writable: raw[i].Events&syscall.EPOLLOUT != 0,
+			Err:      raw[i].Events&(syscall.EPOLLHUP|syscall.EPOLLERR) != 0,
+		}
+	}
+	return n, nil
+}
+
+func (p *epollPoller) Close() error {
+	return syscall.Close(p.epfd)
+}
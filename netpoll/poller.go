@@ -0,0 +1,36 @@
+// Package netpoll provides a small, OS-agnostic readiness-multiplexing
+// abstraction — epoll on Linux, select elsewhere — shared by the server's
+// and client's non-blocking event loops so neither has to special-case the
+// underlying syscall itself.
+package netpoll
+
+// Event reports readiness for a single fd after a Poller.Wait call.
+type Event struct {
+	Fd       int
+	Readable bool
+	Writable bool
+	Err      bool
+}
+
+// Poller is the minimal readiness-multiplexing interface an event loop
+// needs.
+type Poller interface {
+	// Add registers fd for read readiness, and for write readiness too if
+	// writable is true.
+	Add(fd int, writable bool) error
+	// Modify changes the write-readiness interest for an already
+	// registered fd. Read readiness is always on.
+	Modify(fd int, writable bool) error
+	// Remove unregisters fd. It does not close it.
+	Remove(fd int) error
+	// Wait blocks (up to timeoutMs milliseconds, or indefinitely if
+	// negative) and fills events with ready fds, returning how many were
+	// written.
+	Wait(events []Event, timeoutMs int) (int, error)
+	Close() error
+}
+
+// New creates the OS-specific Poller: epoll on Linux, select elsewhere.
+func New() (Poller, error) {
+	return newPoller()
+}
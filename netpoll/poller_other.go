@@ -0,0 +1,99 @@
+//go:build !linux
+
+package netpoll
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// maxSelectFd is one past the highest fd select(2)'s fixed-size fd_set
+// can represent: syscall.FdSet.Bits is a [16]int64, i.e. 1024 bits, and
+// fdSet/fdIsSet index into it as fd/64, so an fd at or beyond this limit
+// would index out of range instead of returning an error.
+const maxSelectFd = len(syscall.FdSet{}.Bits) * 64
+
+// selectPoller implements Poller with select(2) for platforms without
+// epoll. It's a correctness fallback, not a performance match for epoll:
+// select rebuilds its fd sets and rescans every registered fd on every
+// call, so it stops scaling long before epoll does.
+type selectPoller struct {
+	writable map[int]bool
+	maxFd    int
+}
+
+func newPoller() (Poller, error) {
+	return &selectPoller{writable: make(map[int]bool)}, nil
+}
+
+func (p *selectPoller) Add(fd int, writable bool) error {
+	if fd < 0 || fd >= maxSelectFd {
+		return fmt.Errorf("fd %d out of range for select(2) (max %d)", fd, maxSelectFd-1)
+	}
+	p.writable[fd] = writable
+	if fd > p.maxFd {
+		p.maxFd = fd
+	}
+	return nil
+}
+
+func (p *selectPoller) Modify(fd int, writable bool) error {
+	if fd < 0 || fd >= maxSelectFd {
+		return fmt.Errorf("fd %d out of range for select(2) (max %d)", fd, maxSelectFd-1)
+	}
+	p.writable[fd] = writable
+	return nil
+}
+
+func (p *selectPoller) Remove(fd int) error {
+	delete(p.writable, fd)
+	return nil
+}
+
+func (p *selectPoller) Wait(events []Event, timeoutMs int) (int, error) {
+	var rfds, wfds syscall.FdSet
+	for fd, writable := range p.writable {
+		fdSet(&rfds, fd)
+		if writable {
+			fdSet(&wfds, fd)
+		}
+	}
+
+	var tvp *syscall.Timeval // nil: a negative timeoutMs blocks indefinitely
+	if timeoutMs >= 0 {
+		tv := syscall.NsecToTimeval(int64(timeoutMs) * int64(1_000_000))
+		tvp = &tv
+	}
+	if _, err := syscall.Select(p.maxFd+1, &rfds, &wfds, nil, tvp); err != nil {
+		if err == syscall.EINTR {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("select: %v", err)
+	}
+
+	n := 0
+	for fd := range p.writable {
+		if n >= len(events) {
+			break
+		}
+		readable := fdIsSet(&rfds, fd)
+		writableNow := fdIsSet(&wfds, fd)
+		if readable || writableNow {
+			events[n] = Event{Fd: fd, Readable: readable, Writable: writableNow}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (p *selectPoller) Close() error {
+	return nil
+}
+
+func fdSet(set *syscall.FdSet, fd int) {
+	set.Bits[fd/64] |= 1 << (uint(fd) % 64)
+}
+
+func fdIsSet(set *syscall.FdSet, fd int) bool {
+	return set.Bits[fd/64]&(1<<(uint(fd)%64)) != 0
+}
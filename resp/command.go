@@ -0,0 +1,84 @@
+package resp
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Command is a single parsed client request: its name and raw arguments.
+// Dispatch is case-insensitive on Name; Args are passed through
+// unmodified, same as real Redis.
+type Command struct {
+	Name string
+	Args [][]byte
+}
+
+// ErrIncomplete is returned by ParseCommand when buf holds the start of a
+// command but not all of it yet. Callers should wait for more bytes from
+// the socket and retry with the fuller buffer.
+var ErrIncomplete = fmt.Errorf("resp: incomplete command")
+
+// ParseCommand reads one RESP2 array-of-bulk-strings command off the front
+// of buf and returns it along with the number of bytes consumed so the
+// caller can advance past it. It returns ErrIncomplete if buf doesn't yet
+// hold a full command.
+func ParseCommand(buf []byte) (*Command, int, error) {
+	if len(buf) == 0 {
+		return nil, 0, ErrIncomplete
+	}
+	if buf[0] != '*' {
+		return nil, 0, fmt.Errorf("resp: expected array, got %q", buf[0])
+	}
+
+	line, pos, err := readLine(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count < 0 {
+		return nil, 0, fmt.Errorf("resp: bad array length %q", line)
+	}
+
+	args := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		if pos >= len(buf) {
+			return nil, 0, ErrIncomplete
+		}
+		if buf[pos] != '$' {
+			return nil, 0, fmt.Errorf("resp: expected bulk string, got %q", buf[pos])
+		}
+
+		header, headerLen, err := readLine(buf[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		size, err := strconv.Atoi(string(header[1:]))
+		if err != nil || size < 0 {
+			return nil, 0, fmt.Errorf("resp: bad bulk length %q", header)
+		}
+
+		pos += headerLen
+		if pos+size+2 > len(buf) {
+			return nil, 0, ErrIncomplete
+		}
+		args = append(args, buf[pos:pos+size])
+		pos += size + 2 // payload plus trailing \r\n
+	}
+
+	if len(args) == 0 {
+		return nil, 0, fmt.Errorf("resp: empty command")
+	}
+	return &Command{Name: string(bytes.ToUpper(args[0])), Args: args[1:]}, pos, nil
+}
+
+// readLine returns the bytes up to (excluding) the first \r\n in buf, plus
+// the total length consumed including the \r\n. It returns ErrIncomplete
+// if no \r\n has arrived yet.
+func readLine(buf []byte) ([]byte, int, error) {
+	idx := bytes.Index(buf, []byte("\r\n"))
+	if idx < 0 {
+		return nil, 0, ErrIncomplete
+	}
+	return buf[:idx], idx + 2, nil
+}
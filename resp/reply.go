@@ -0,0 +1,100 @@
+// Package resp implements the RESP2 wire protocol that real Redis and
+// redis-cli speak: simple strings, errors, integers, bulk strings, and
+// arrays of any of the above.
+package resp
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Type identifies which of the five RESP2 value kinds a Reply holds.
+type Type int
+
+const (
+	SimpleString Type = iota
+	Error
+	Integer
+	Bulk
+	Array
+)
+
+// Reply is a single RESP2 value. The same type serialises a server's
+// command reply and (as an Array of Bulk) a client's command.
+type Reply struct {
+	Type  Type
+	Str   string  // SimpleString / Error payload
+	Int   int64   // Integer payload
+	Bulk  []byte  // Bulk payload; nil means a RESP nil bulk string ($-1)
+	Array []Reply // Array payload; nil means a RESP nil array (*-1)
+}
+
+// OK is the conventional "+OK" reply most write commands return.
+var OK = Reply{Type: SimpleString, Str: "OK"}
+
+// NilBulk is the RESP2 "$-1\r\n" reply used for a missing key.
+var NilBulk = Reply{Type: Bulk, Bulk: nil}
+
+// NewSimpleString builds a "+..." reply.
+func NewSimpleString(s string) Reply {
+	return Reply{Type: SimpleString, Str: s}
+}
+
+// NewError builds a "-..." reply from a format string, same convention as
+// fmt.Errorf.
+func NewError(format string, a ...interface{}) Reply {
+	return Reply{Type: Error, Str: fmt.Sprintf(format, a...)}
+}
+
+// NewInteger builds a ":..." reply.
+func NewInteger(n int64) Reply {
+	return Reply{Type: Integer, Int: n}
+}
+
+// NewBulk builds a "$..." reply. A nil slice becomes a nil bulk reply
+// ($-1), same as real Redis returning nil for a missing key.
+func NewBulk(b []byte) Reply {
+	if b == nil {
+		return NilBulk
+	}
+	return Reply{Type: Bulk, Bulk: b}
+}
+
+// NewArray builds a "*..." reply out of already-built replies.
+func NewArray(items []Reply) Reply {
+	return Reply{Type: Array, Array: items}
+}
+
+// Encode serialises r into its RESP2 wire representation.
+func (r Reply) Encode() []byte {
+	switch r.Type {
+	case SimpleString:
+		return []byte("+" + r.Str + "\r\n")
+	case Error:
+		return []byte("-" + r.Str + "\r\n")
+	case Integer:
+		return []byte(":" + strconv.FormatInt(r.Int, 10) + "\r\n")
+	case Bulk:
+		if r.Bulk == nil {
+			return []byte("$-1\r\n")
+		}
+		out := make([]byte, 0, len(r.Bulk)+16)
+		out = append(out, '$')
+		out = strconv.AppendInt(out, int64(len(r.Bulk)), 10)
+		out = append(out, '\r', '\n')
+		out = append(out, r.Bulk...)
+		out = append(out, '\r', '\n')
+		return out
+	case Array:
+		if r.Array == nil {
+			return []byte("*-1\r\n")
+		}
+		out := []byte("*" + strconv.Itoa(len(r.Array)) + "\r\n")
+		for _, item := range r.Array {
+			out = append(out, item.Encode()...)
+		}
+		return out
+	default:
+		return []byte("-ERR unknown reply type\r\n")
+	}
+}
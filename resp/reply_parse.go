@@ -0,0 +1,88 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParseReply reads one RESP2 reply value off the front of buf — a
+// SimpleString, Error, Integer, Bulk, or (possibly nested) Array — and
+// returns it along with the number of bytes consumed. It returns
+// ErrIncomplete if buf doesn't yet hold a full reply, same convention as
+// ParseCommand.
+func ParseReply(buf []byte) (Reply, int, error) {
+	if len(buf) == 0 {
+		return Reply{}, 0, ErrIncomplete
+	}
+
+	switch buf[0] {
+	case '+':
+		line, n, err := readLine(buf)
+		if err != nil {
+			return Reply{}, 0, err
+		}
+		return NewSimpleString(string(line[1:])), n, nil
+
+	case '-':
+		line, n, err := readLine(buf)
+		if err != nil {
+			return Reply{}, 0, err
+		}
+		return NewError("%s", string(line[1:])), n, nil
+
+	case ':':
+		line, n, err := readLine(buf)
+		if err != nil {
+			return Reply{}, 0, err
+		}
+		i, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return Reply{}, 0, fmt.Errorf("resp: bad integer %q", line)
+		}
+		return NewInteger(i), n, nil
+
+	case '$':
+		header, pos, err := readLine(buf)
+		if err != nil {
+			return Reply{}, 0, err
+		}
+		size, err := strconv.Atoi(string(header[1:]))
+		if err != nil {
+			return Reply{}, 0, fmt.Errorf("resp: bad bulk length %q", header)
+		}
+		if size < 0 {
+			return NilBulk, pos, nil // $-1
+		}
+		if pos+size+2 > len(buf) {
+			return Reply{}, 0, ErrIncomplete
+		}
+		return NewBulk(append([]byte(nil), buf[pos:pos+size]...)), pos + size + 2, nil
+
+	case '*':
+		header, pos, err := readLine(buf)
+		if err != nil {
+			return Reply{}, 0, err
+		}
+		count, err := strconv.Atoi(string(header[1:]))
+		if err != nil {
+			return Reply{}, 0, fmt.Errorf("resp: bad array length %q", header)
+		}
+		if count < 0 {
+			return NewArray(nil), pos, nil // *-1
+		}
+
+		items := make([]Reply, 0, count)
+		for i := 0; i < count; i++ {
+			item, n, err := ParseReply(buf[pos:])
+			if err != nil {
+				return Reply{}, 0, err
+			}
+			items = append(items, item)
+			pos += n
+		}
+		return NewArray(items), pos, nil
+
+	default:
+		return Reply{}, 0, fmt.Errorf("resp: unknown reply type %q", buf[0])
+	}
+}
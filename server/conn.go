@@ -0,0 +1,37 @@
+package main
+
+// ConnState tracks where a connection is in the request/response cycle so
+// the event loop knows whether it should be waiting to read, waiting to
+// flush a reply, or ready to be torn down.
+type ConnState int
+
+const (
+	// StateReq means the connection is waiting for a complete request to
+	// arrive in rbuf.
+	StateReq ConnState = iota
+	// StateRes means a reply is sitting in wbuf waiting to be flushed.
+	StateRes
+	// StateEnd means the connection is done and should be closed on the
+	// next pass through the event loop.
+	StateEnd
+)
+
+// Conn holds the per-connection state the event loop needs: the raw fd and
+// the inbound/outbound byte buffers that take the place of the single
+// stack-allocated rbuf/wbuf the old blocking one_request used.
+type Conn struct {
+	fd    int
+	state ConnState
+	rbuf  []byte // bytes read from fd but not yet parsed into a request
+	wbuf  []byte // bytes queued to write to fd but not yet flushed
+}
+
+// newConn wraps an accepted, non-blocking fd ready to be driven by the
+// event loop.
+func newConn(fd int) *Conn {
+	return &Conn{
+		fd:    fd,
+		state: StateReq,
+		rbuf:  make([]byte, 0, 4+kMaxMsg),
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"redis-scratch/resp"
+	"redis-scratch/store"
+)
+
+// keyspace is the process-wide key/value store shared by every connection,
+// including across -workers goroutines.
+var keyspace = store.New()
+
+// commands maps an upper-cased command name to its handler. Handlers get
+// the connection they were received on (unused today, but command
+// implementations like CLIENT or MULTI will eventually need it) and the
+// raw argument bytes that followed the command name.
+var commands = map[string]func(*Conn, [][]byte) resp.Reply{
+	"PING":    cmdPing,
+	"ECHO":    cmdEcho,
+	"GET":     cmdGet,
+	"SET":     cmdSet,
+	"DEL":     cmdDel,
+	"EXISTS":  cmdExists,
+	"KEYS":    cmdKeys,
+	"TTL":     cmdTTL,
+	"EXPIRE":  cmdExpire,
+	"PERSIST": cmdPersist,
+}
+
+// dispatch looks up and runs the handler for cmd, returning a RESP error
+// reply for unknown commands instead of failing the connection.
+func dispatch(conn *Conn, cmd *resp.Command) resp.Reply {
+	handler, ok := commands[cmd.Name]
+	if !ok {
+		return resp.NewError("ERR unknown command '%s'", cmd.Name)
+	}
+	return handler(conn, cmd.Args)
+}
+
+func cmdPing(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return resp.NewSimpleString("PONG")
+	}
+	return resp.NewBulk(args[0])
+}
+
+func cmdEcho(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) != 1 {
+		return resp.NewError("ERR wrong number of arguments for 'echo' command")
+	}
+	return resp.NewBulk(args[0])
+}
+
+func cmdGet(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) != 1 {
+		return resp.NewError("ERR wrong number of arguments for 'get' command")
+	}
+	val, ok := keyspace.Get(string(args[0]))
+	if !ok {
+		return resp.NilBulk
+	}
+	return resp.NewBulk(val)
+}
+
+// cmdSet implements SET key val [EX seconds | PX milliseconds].
+func cmdSet(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) < 2 {
+		return resp.NewError("ERR wrong number of arguments for 'set' command")
+	}
+	key, val := string(args[0]), args[1]
+
+	var expireAt time.Time
+	opts := args[2:]
+	for i := 0; i < len(opts); i++ {
+		switch strings.ToUpper(string(opts[i])) {
+		case "EX":
+			n, ok := parseOptArg(opts, i)
+			if !ok {
+				return resp.NewError("ERR value is not an integer or out of range")
+			}
+			expireAt = time.Now().Add(time.Duration(n) * time.Second)
+			i++
+		case "PX":
+			n, ok := parseOptArg(opts, i)
+			if !ok {
+				return resp.NewError("ERR value is not an integer or out of range")
+			}
+			expireAt = time.Now().Add(time.Duration(n) * time.Millisecond)
+			i++
+		default:
+			return resp.NewError("ERR syntax error")
+		}
+	}
+
+	keyspace.Set(key, val, expireAt)
+	return resp.OK
+}
+
+// parseOptArg parses the integer argument following opts[i] (an EX/PX
+// option name), returning false if it's missing or not an integer.
+func parseOptArg(opts [][]byte, i int) (int64, bool) {
+	if i+1 >= len(opts) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(opts[i+1]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func cmdDel(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return resp.NewError("ERR wrong number of arguments for 'del' command")
+	}
+	return resp.NewInteger(int64(keyspace.Del(toStrings(args)...)))
+}
+
+func cmdExists(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return resp.NewError("ERR wrong number of arguments for 'exists' command")
+	}
+	return resp.NewInteger(int64(keyspace.Exists(toStrings(args)...)))
+}
+
+func cmdKeys(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) != 1 {
+		return resp.NewError("ERR wrong number of arguments for 'keys' command")
+	}
+	// The pattern argument is not matched: every live key is returned,
+	// whatever the pattern, as if it were "*".
+	all := keyspace.Keys()
+	items := make([]resp.Reply, 0, len(all))
+	for _, key := range all {
+		items = append(items, resp.NewBulk([]byte(key)))
+	}
+	return resp.NewArray(items)
+}
+
+// cmdTTL reports remaining seconds to live: -2 if the key doesn't exist,
+// -1 if it exists but has no expiry, same convention as real Redis.
+func cmdTTL(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) != 1 {
+		return resp.NewError("ERR wrong number of arguments for 'ttl' command")
+	}
+	ttl, hasExpiry, ok := keyspace.TTL(string(args[0]))
+	if !ok {
+		return resp.NewInteger(-2)
+	}
+	if !hasExpiry {
+		return resp.NewInteger(-1)
+	}
+	secs := int64(ttl.Seconds())
+	if secs < 0 {
+		secs = 0
+	}
+	return resp.NewInteger(secs)
+}
+
+func cmdExpire(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) != 2 {
+		return resp.NewError("ERR wrong number of arguments for 'expire' command")
+	}
+	secs, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return resp.NewError("ERR value is not an integer or out of range")
+	}
+	if !keyspace.Expire(string(args[0]), time.Now().Add(time.Duration(secs)*time.Second)) {
+		return resp.NewInteger(0)
+	}
+	return resp.NewInteger(1)
+}
+
+func cmdPersist(conn *Conn, args [][]byte) resp.Reply {
+	if len(args) != 1 {
+		return resp.NewError("ERR wrong number of arguments for 'persist' command")
+	}
+	if !keyspace.Persist(string(args[0])) {
+		return resp.NewInteger(0)
+	}
+	return resp.NewInteger(1)
+}
+
+func toStrings(args [][]byte) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = string(a)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+
+	"redis-scratch/netpoll"
+)
+
+// startBenchServer binds an ephemeral port, registers it with a fresh
+// poller, and runs the real event loop in a background goroutine so
+// benchmarks can drive it over a loopback socket exactly like a client
+// would. It cleans itself up via tb.Cleanup.
+func startBenchServer(tb testing.TB) *syscall.SockaddrInet4 {
+	tb.Helper()
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	if err != nil {
+		tb.Fatalf("socket: %v", err)
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		tb.Fatalf("setsockopt: %v", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Port: 0, Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		tb.Fatalf("bind: %v", err)
+	}
+	if err := syscall.Listen(fd, syscall.SOMAXCONN); err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		tb.Fatalf("getsockname: %v", err)
+	}
+	addr, ok := sa.(*syscall.SockaddrInet4)
+	if !ok {
+		tb.Fatalf("unexpected sockaddr type %T", sa)
+	}
+
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		tb.Fatalf("set non-blocking: %v", err)
+	}
+
+	poller, err := netpoll.New()
+	if err != nil {
+		tb.Fatalf("new poller: %v", err)
+	}
+	if err := poller.Add(fd, false); err != nil {
+		tb.Fatalf("poller add: %v", err)
+	}
+
+	go runEventLoop(fd, poller)
+	tb.Cleanup(func() {
+		poller.Close()
+		syscall.Close(fd)
+	})
+
+	return addr
+}
+
+// BenchmarkPipelinedRequests sends b.N PING commands pipelined over a
+// single connection in one write, then waits for all b.N replies. Since
+// drainRequests answers every command that has arrived by the time the
+// socket is drained and handleWritable keeps the connection open for more,
+// this exercises the full pipelining path end to end: comparing elapsed
+// time across -bench runs with different -benchtime=Nx values shows throughput
+// tracking the number of pipelined commands rather than the number of
+// round trips.
+func BenchmarkPipelinedRequests(b *testing.B) {
+	addr := startBenchServer(b)
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	if err != nil {
+		b.Fatalf("socket: %v", err)
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Connect(fd, addr); err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+
+	const ping = "*1\r\n$4\r\nPING\r\n"
+	req := make([]byte, 0, len(ping)*b.N)
+	for i := 0; i < b.N; i++ {
+		req = append(req, ping...)
+	}
+
+	b.ResetTimer()
+
+	if err := write_full(fd, req); err != nil {
+		b.Fatalf("write_full: %v", err)
+	}
+
+	const wantPerReply = len("+PONG\r\n")
+	want := wantPerReply * b.N
+	got := 0
+	buf := make([]byte, 64*1024)
+	for got < want {
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			b.Fatalf("read: %v", err)
+		}
+		got += n
+	}
+}
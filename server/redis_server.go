@@ -2,12 +2,23 @@ package main
 
 import (
 	"encoding/binary"
+	"flag"
 	"fmt"
+	"sync"
 	"syscall"
+	"time"
+
+	"redis-scratch/netpoll"
+	"redis-scratch/resp"
 )
 
 const kMaxMsg = 4096
 
+// activeExpireTickMs bounds how long the event loop's poller.Wait can
+// block, so keyspace.ExpireActive still runs periodically even when no fd
+// is ready.
+const activeExpireTickMs = 100
+
 // read_full ensures that exactly 'len' bytes are read from the file descriptor
 // This function handles partial reads by continuing to read until all requested bytes are received
 //
@@ -142,6 +153,27 @@ func one_request(connfd int) error {
 	return nil
 }
 
+// runLegacyLoop serves the original blocking accept -> one_request -> close
+// cycle, preserved behind -proto=legacy for callers (and tests) that still
+// speak the length-prefixed framing instead of RESP2.
+func runLegacyLoop(fd int) {
+	fmt.Println("Redis server listening on 127.0.0.1:8000 (legacy length-prefixed protocol)")
+
+	for {
+		connfd, sa, err := syscall.Accept(fd)
+		if err != nil {
+			fmt.Printf("Error accepting connection: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Accepted connection from %v\n", sa)
+
+		one_request(connfd)
+
+		syscall.Close(connfd)
+	}
+}
+
 // handleConnection processes incoming client connections by reading data and sending a response
 // This function demonstrates basic socket I/O operations for a Redis-like server
 //
@@ -206,157 +238,314 @@ func handleConnection(connfd int) {
 	}
 }
 
-// main function initializes and runs a basic Redis-like TCP server
-// This demonstrates low-level socket programming using system calls
-//
-// The server performs the following operations:
-// 1. Creates a TCP socket
-// 2. Sets socket options for address reuse
-// 3. Binds to localhost:8000
-// 4. Listens for incoming connections
-// 5. Accepts and handles client connections in a loop
-//
-// Example server lifecycle:
-//   socket() -> setsockopt() -> bind() -> listen() -> accept() -> handle -> close()
-func main() {
-	// syscall.Socket creates a new socket and returns its file descriptor
-	// This is a low-level interface to the operating system's socket API
-	//
-	// Parameters:
-	//   - domain (int): Address family (syscall.AF_INET for IPv4, syscall.AF_INET6 for IPv6)
-	//   - typ (int): Socket type (syscall.SOCK_STREAM for TCP, syscall.SOCK_DGRAM for UDP)
-	//   - proto (int): Protocol (syscall.IPPROTO_TCP for TCP, syscall.IPPROTO_UDP for UDP)
-	//
-	// Returns:
-	//   - fd (int): File descriptor of the created socket
-	//   - err (error): Error if socket creation failed
-	//
-	// Example usage:
-	//   fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
-	//   // Creates a TCP socket for IPv4 communication
-	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+// acceptAll drains every pending connection off the listening socket into
+// non-blocking Conns registered with the poller. Because the listening
+// socket itself is non-blocking, Accept returns EAGAIN once the backlog is
+// empty instead of blocking the event loop.
+func acceptAll(listenFd int, poller netpoll.Poller, conns map[int]*Conn) {
+	for {
+		connFd, sa, err := syscall.Accept(listenFd)
+		if err != nil {
+			if err != syscall.EAGAIN {
+				fmt.Printf("Error accepting connection: %v\n", err)
+			}
+			return
+		}
+
+		if err := syscall.SetNonblock(connFd, true); err != nil {
+			fmt.Printf("Error setting connection non-blocking: %v\n", err)
+			syscall.Close(connFd)
+			continue
+		}
+
+		if err := poller.Add(connFd, false); err != nil {
+			fmt.Printf("Error registering connection: %v\n", err)
+			syscall.Close(connFd)
+			continue
+		}
+
+		fmt.Printf("Accepted connection from %v\n", sa)
+		conns[connFd] = newConn(connFd)
+	}
+}
+
+// handleReadable drains conn.fd into conn.rbuf until EAGAIN, then answers
+// every complete request that has accumulated so far, so a client that
+// pipelines many commands in one write gets all of them dispatched from a
+// single readiness notification.
+func handleReadable(poller netpoll.Poller, conn *Conn) {
+	tmp := make([]byte, 4+kMaxMsg)
+	for {
+		n, err := syscall.Read(conn.fd, tmp)
+		if err != nil {
+			if err == syscall.EAGAIN {
+				break
+			}
+			conn.state = StateEnd
+			return
+		}
+		if n == 0 {
+			conn.state = StateEnd
+			return
+		}
+		conn.rbuf = append(conn.rbuf, tmp[:n]...)
+	}
+
+	if conn.state != StateEnd {
+		drainRequests(conn)
+	}
+
+	if conn.state == StateRes {
+		if err := poller.Modify(conn.fd, true); err != nil {
+			fmt.Printf("Error switching connection to writable: %v\n", err)
+			conn.state = StateEnd
+		}
+	}
+}
+
+// drainRequests is the event-loop equivalent of one_request for the RESP
+// protocol, except it keeps going: it consumes every complete command
+// sitting at the head of conn.rbuf, dispatching each one and appending its
+// encoded reply to conn.wbuf, until only a partial command (or nothing)
+// remains.
+func drainRequests(conn *Conn) {
+	for {
+		cmd, n, err := resp.ParseCommand(conn.rbuf)
+		if err == resp.ErrIncomplete {
+			return
+		}
+		if err != nil {
+			fmt.Println("resp parse error:", err)
+			conn.state = StateEnd
+			return
+		}
+
+		reply := dispatch(conn, cmd)
+		conn.rbuf = conn.rbuf[n:]
+		conn.wbuf = append(conn.wbuf, reply.Encode()...)
+		conn.state = StateRes
+	}
+}
+
+// handleWritable flushes as much of conn.wbuf as the socket will currently
+// accept. Once everything queued has been flushed the connection goes back
+// to waiting for more requests instead of closing, so one connection can
+// serve many pipelined round trips rather than the old one-request-then-
+// close behaviour of one_request.
+func handleWritable(poller netpoll.Poller, conn *Conn) {
+	for len(conn.wbuf) > 0 {
+		n, err := syscall.Write(conn.fd, conn.wbuf)
+		if err != nil {
+			if err == syscall.EAGAIN {
+				return
+			}
+			conn.state = StateEnd
+			return
+		}
+		conn.wbuf = conn.wbuf[n:]
+	}
+
+	conn.state = StateReq
+	if err := poller.Modify(conn.fd, false); err != nil {
+		fmt.Printf("Error switching connection back to read-only: %v\n", err)
+		conn.state = StateEnd
+	}
+}
+
+// closeConn unregisters and closes a connection and drops it from the
+// connection table.
+func closeConn(poller netpoll.Poller, conns map[int]*Conn, conn *Conn) {
+	poller.Remove(conn.fd)
+	syscall.Close(conn.fd)
+	delete(conns, conn.fd)
+}
 
+// newListener creates, binds, and starts listening on 127.0.0.1:8000. When
+// reusePort is true it also sets SO_REUSEPORT before binding, so several
+// workers can each hold their own listening socket on the same address and
+// let the kernel load-balance inbound connections across them instead of
+// serialising every Accept through a single socket.
+func newListener(reusePort bool) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
 	if err != nil {
-		// fmt.Printf formats and prints to standard output
-		// Parameters: fmt.Printf(format string, a ...interface{})
-		// Example: fmt.Printf("Error: %v\n", err)
-		fmt.Printf("Error creating socket: %v\n", err)
-		return
+		return -1, fmt.Errorf("socket: %v", err)
+	}
+
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("setsockopt SO_REUSEADDR: %v", err)
+	}
+
+	if reusePort {
+		if err := setReusePort(fd); err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("setsockopt SO_REUSEPORT: %v", err)
+		}
+	}
+
+	addr := syscall.SockaddrInet4{Port: 8000, Addr: [4]byte{127, 0, 0, 1}}
+	if err := syscall.Bind(fd, &addr); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("bind: %v", err)
 	}
+	if err := syscall.Listen(fd, syscall.SOMAXCONN); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("listen: %v", err)
+	}
+
+	return fd, nil
+}
 
-	// defer schedules a function call to be run when the surrounding function returns
-	// Parameters: defer function_call
-	// Example: defer file.Close() - ensures file is closed when function exits
+// runWorker runs one SO_REUSEPORT worker: its own listening socket, its own
+// poller, its own event loop. Workers share nothing but the process-wide
+// keyspace (store), so each one independently serves whatever connections
+// the kernel routes to it.
+func runWorker(id int) error {
+	fd, err := newListener(true)
+	if err != nil {
+		return fmt.Errorf("worker %d: %v", id, err)
+	}
 	defer syscall.Close(fd)
 
-	// syscall.SetsockoptInt sets an integer socket option
-	// This allows the socket to reuse the address immediately after closing
-	//
-	// Parameters:
-	//   - fd (int): Socket file descriptor
-	//   - level (int): Protocol level (syscall.SOL_SOCKET for socket-level options)
-	//   - name (int): Option name (syscall.SO_REUSEADDR allows address reuse)
-	//   - value (int): Option value (1 to enable, 0 to disable)
-	//
-	// Returns:
-	//   - err (error): Error if setting the option failed
-	//
-	// Example usage:
-	//   err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
-	//   // Enables address reuse to avoid "address already in use" errors
-	err = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return fmt.Errorf("worker %d: set non-blocking: %v", id, err)
+	}
 
+	poller, err := netpoll.New()
 	if err != nil {
-		fmt.Printf("Error setting socket option: %v\n", err)
-		return
+		return fmt.Errorf("worker %d: new poller: %v", id, err)
 	}
+	defer poller.Close()
 
-	// syscall.SockaddrInet4 represents an IPv4 socket address
-	// Fields:
-	//   - Port (int): Port number (8000 in this case)
-	//   - Addr ([4]byte): IPv4 address as 4-byte array
-	//
-	// Example addresses:
-	//   - [4]byte{127, 0, 0, 1} = localhost (127.0.0.1)
-	//   - [4]byte{0, 0, 0, 0} = all interfaces (0.0.0.0)
-	//   - [4]byte{192, 168, 1, 100} = 192.168.1.100
-	addr := syscall.SockaddrInet4{
-		Port: 8000,
-		Addr: [4]byte{127, 0, 0, 1},
+	if err := poller.Add(fd, false); err != nil {
+		return fmt.Errorf("worker %d: register listening socket: %v", id, err)
 	}
 
-	// syscall.Bind associates a socket with a specific address and port
-	//
-	// Parameters:
-	//   - fd (int): Socket file descriptor
-	//   - sa (syscall.Sockaddr): Socket address structure
-	//
-	// Returns:
-	//   - err (error): Error if binding failed
-	//
-	// Example usage:
-	//   addr := syscall.SockaddrInet4{Port: 8080, Addr: [4]byte{0, 0, 0, 0}}
-	//   err := syscall.Bind(fd, &addr)
-	//   // Binds socket to port 8080 on all interfaces
-	err = syscall.Bind(fd, &addr)
+	runEventLoop(fd, poller)
+	return nil
+}
+
+// runWorkers starts n SO_REUSEPORT workers and blocks until all of them
+// have exited (which, barring a fatal setup error, is never: runEventLoop
+// only returns on a poller failure).
+func runWorkers(n int) {
+	fmt.Printf("Redis server listening on 127.0.0.1:8000 across %d workers (SO_REUSEPORT)\n", n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if err := runWorker(id); err != nil {
+				fmt.Printf("Error in worker %d: %v\n", id, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
 
+// main function initializes and runs a non-blocking, epoll-driven
+// Redis-like TCP server.
+//
+// By default it runs a single listening socket driven by one event loop.
+// With -workers=N>1 it instead starts N independent SO_REUSEPORT workers,
+// each with its own socket and event loop, so the kernel spreads inbound
+// connections across them instead of funnelling every Accept through one
+// goroutine.
+func main() {
+	proto := flag.String("proto", "resp", `wire protocol to speak: "resp" (the default, RESP2) or "legacy" for the original length-prefixed framing`)
+	workers := flag.Int("workers", 1, "number of independent SO_REUSEPORT listening sockets/event loops to run (values above 1 are rejected with -proto=legacy)")
+	flag.Parse()
+
+	if *workers > 1 {
+		if *proto == "legacy" {
+			fmt.Println("Error: -workers requires -proto=resp; the legacy framing doesn't support SO_REUSEPORT multiplexing")
+			return
+		}
+		runWorkers(*workers)
+		return
+	}
+
+	fd, err := newListener(false)
 	if err != nil {
-		fmt.Printf("Error binding socket: %v\n", err)
+		fmt.Printf("Error creating listening socket: %v\n", err)
 		return
 	}
+	defer syscall.Close(fd)
 
-	// syscall.Listen marks the socket as a passive socket for accepting connections
-	//
-	// Parameters:
-	//   - fd (int): Socket file descriptor
-	//   - backlog (int): Maximum number of pending connections (syscall.SOMAXCONN for system maximum)
-	//
-	// Returns:
-	//   - err (error): Error if listen failed
-	//
-	// Example usage:
-	//   err := syscall.Listen(fd, 10)  // Allow up to 10 pending connections
-	//   err := syscall.Listen(fd, syscall.SOMAXCONN)  // Use system maximum
-	err = syscall.Listen(fd, syscall.SOMAXCONN)
+	if *proto == "legacy" {
+		runLegacyLoop(fd)
+		return
+	}
+
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		fmt.Printf("Error setting listening socket non-blocking: %v\n", err)
+		return
+	}
 
+	poller, err := netpoll.New()
 	if err != nil {
-		fmt.Printf("Error listening on socket: %v\n", err)
+		fmt.Printf("Error creating poller: %v\n", err)
+		return
+	}
+	defer poller.Close()
+
+	if err := poller.Add(fd, false); err != nil {
+		fmt.Printf("Error registering listening socket: %v\n", err)
 		return
 	}
 
 	fmt.Println("Redis server listening on 127.0.0.1:8000")
 
-	// Infinite loop to continuously accept and handle client connections
-	for {
-		// syscall.Accept accepts an incoming connection on a listening socket
-		//
-		// Parameters:
-		//   - fd (int): Listening socket file descriptor
-		//
-		// Returns:
-		//   - connfd (int): File descriptor for the new connection
-		//   - sa (syscall.Sockaddr): Address of the connecting client
-		//   - err (error): Error if accept failed
-		//
-		// Example usage:
-		//   connfd, clientAddr, err := syscall.Accept(serverfd)
-		//   // connfd is used to communicate with the specific client
-		//   // clientAddr contains the client's IP and port information
-		connfd, sa, err := syscall.Accept(fd)
+	runEventLoop(fd, poller)
+}
 
+// runEventLoop is the single-goroutine event loop: it blocks in Wait()
+// until some fd is ready, then drives the listening socket and every open
+// connection without ever blocking on one slow client the way
+// one_request/Accept used to. Pulled out of main so benchmarks can drive it
+// against a real listening socket without spawning a whole process.
+func runEventLoop(listenFd int, poller netpoll.Poller) {
+	conns := make(map[int]*Conn)
+	events := make([]netpoll.Event, 128)
+
+	for {
+		// A finite timeout instead of blocking indefinitely lets the
+		// loop check the expiry heap even on an idle server.
+		n, err := poller.Wait(events, activeExpireTickMs)
 		if err != nil {
-			fmt.Printf("Error accepting connection: %v\n", err)
+			fmt.Printf("Error polling for events: %v\n", err)
 			return
 		}
 
-		fmt.Printf("Accepted connection from %v\n", sa)
-
-		// handleConnection(connfd)
-		one_request(connfd)
-
-		// syscall.Close closes a file descriptor
-		// Parameters: syscall.Close(fd int)
-		// Example: syscall.Close(connfd) - closes the client connection
-		syscall.Close(connfd)
+		keyspace.ExpireActive(time.Now())
+
+		for i := 0; i < n; i++ {
+			ev := events[i]
+
+			if ev.Fd == listenFd {
+				acceptAll(listenFd, poller, conns)
+				continue
+			}
+
+			conn, ok := conns[ev.Fd]
+			if !ok {
+				continue
+			}
+
+			if ev.Err {
+				closeConn(poller, conns, conn)
+				continue
+			}
+			if ev.Readable {
+				handleReadable(poller, conn)
+			}
+			if ev.Writable && conn.state != StateEnd {
+				handleWritable(poller, conn)
+			}
+			if conn.state == StateEnd {
+				closeConn(poller, conns, conn)
+			}
+		}
 	}
 }
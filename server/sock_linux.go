@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// soReusePort is SO_REUSEPORT on Linux. The syscall package doesn't export
+// it directly, so each OS that supports it gets its own small constant
+// file; sock_other.go covers platforms that don't.
+const soReusePort = 0xf // 15
+
+// setReusePort enables SO_REUSEPORT on fd so multiple worker sockets can
+// bind the same address/port and have the kernel load-balance connections
+// across them.
+func setReusePort(fd int) error {
+	return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, soReusePort, 1)
+}
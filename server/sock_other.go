@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import "fmt"
+
+// setReusePort reports an error on platforms where SO_REUSEPORT isn't
+// defined here. -workers>1 needs it to bind the same address from several
+// sockets; add a constant file like sock_linux.go for a new OS to support
+// it there too.
+func setReusePort(fd int) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on this platform")
+}
@@ -0,0 +1,30 @@
+package store
+
+import "time"
+
+// heapItem is a single (expireAt, key) pair tracked by the expiry heap.
+type heapItem struct {
+	expireAt time.Time
+	key      string
+}
+
+// expiryHeap is a container/heap.Interface min-heap ordered by expireAt. It
+// lets ExpireActive always look at the soonest-expiring key in O(log n)
+// instead of scanning the whole keyspace every tick.
+type expiryHeap []heapItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expireAt.Before(h[j].expireAt) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x interface{}) {
+	*h = append(*h, x.(heapItem))
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
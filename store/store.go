@@ -0,0 +1,212 @@
+// Package store implements the in-memory keyspace backing the command
+// dispatcher: a map of typed values, each with an optional expiry, plus a
+// min-heap of pending expirations that drives active expiration alongside
+// the lazy check every read already does.
+package store
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// ValueType tags what kind of Redis value an entry holds. Only TypeString
+// is produced by any command today; TypeList/TypeHash exist so list/hash
+// commands have somewhere to record their kind later.
+type ValueType int
+
+const (
+	TypeString ValueType = iota
+	TypeList
+	TypeHash
+)
+
+type entry struct {
+	typ      ValueType
+	data     []byte
+	expireAt time.Time // zero value means "no expiry"
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !e.expireAt.After(now)
+}
+
+// Keyspace is the interface the command dispatcher talks to, kept
+// separate from *Store so a later lock-free or sharded implementation can
+// drop in without the dispatcher noticing.
+type Keyspace interface {
+	Set(key string, val []byte, expireAt time.Time)
+	Get(key string) ([]byte, bool)
+	Del(keys ...string) int
+	Exists(keys ...string) int
+	Keys() []string
+	TTL(key string) (ttl time.Duration, hasExpiry bool, ok bool)
+	Expire(key string, expireAt time.Time) bool
+	Persist(key string) bool
+}
+
+// Store is a Keyspace backed by a single map guarded by a sync.RWMutex and
+// a min-heap of pending expirations.
+type Store struct {
+	mu   sync.RWMutex
+	data map[string]*entry
+	exp  expiryHeap
+}
+
+// New creates an empty Store.
+func New() *Store {
+	return &Store{data: make(map[string]*entry)}
+}
+
+// Set stores val under key as a string value. A zero expireAt means the
+// key never expires.
+func (s *Store) Set(key string, val []byte, expireAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = &entry{typ: TypeString, data: val, expireAt: expireAt}
+	if !expireAt.IsZero() {
+		heap.Push(&s.exp, heapItem{expireAt: expireAt, key: key})
+	}
+}
+
+// Get returns the string stored under key. ok is false if the key is
+// missing or has expired; expiry is checked inline here, the "lazy" half
+// of expiration (ExpireActive is the other half).
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lockedLiveGet(key)
+	if !ok {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// lockedLiveGet looks up key, lazily deleting and reporting it missing if
+// it has expired. Callers must hold s.mu for writing.
+func (s *Store) lockedLiveGet(key string) (*entry, bool) {
+	e, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		delete(s.data, key)
+		return nil, false
+	}
+	return e, true
+}
+
+// Del removes keys, returning how many actually existed.
+func (s *Store) Del(keys ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, key := range keys {
+		if _, ok := s.lockedLiveGet(key); ok {
+			delete(s.data, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Exists returns how many of keys are present (and unexpired).
+func (s *Store) Exists(keys ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := 0
+	for _, key := range keys {
+		if _, ok := s.lockedLiveGet(key); ok {
+			found++
+		}
+	}
+	return found
+}
+
+// Keys returns every live key. Like real Redis's KEYS, this is O(n) and
+// meant for debugging, not hot paths.
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		if _, ok := s.lockedLiveGet(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TTL reports the remaining time to live for key. ok is false if the key
+// doesn't exist. hasExpiry is false if the key exists but never expires.
+func (s *Store) TTL(key string) (ttl time.Duration, hasExpiry bool, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, exists := s.lockedLiveGet(key)
+	if !exists {
+		return 0, false, false
+	}
+	if e.expireAt.IsZero() {
+		return 0, false, true
+	}
+	return e.expireAt.Sub(time.Now()), true, true
+}
+
+// Expire sets key's expiry to expireAt, returning false if key doesn't
+// exist.
+func (s *Store) Expire(key string, expireAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lockedLiveGet(key)
+	if !ok {
+		return false
+	}
+	e.expireAt = expireAt
+	heap.Push(&s.exp, heapItem{expireAt: expireAt, key: key})
+	return true
+}
+
+// Persist clears key's expiry, returning true if it had one.
+func (s *Store) Persist(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lockedLiveGet(key)
+	if !ok || e.expireAt.IsZero() {
+		return false
+	}
+	e.expireAt = time.Time{}
+	return true
+}
+
+// ExpireActive pops and deletes every heap entry whose time has passed.
+// It's meant to be called periodically (e.g. once per event loop tick) so
+// keys nobody ever reads again still get reclaimed, instead of relying
+// solely on the lazy check in Get/Del/Exists/Keys.
+func (s *Store) ExpireActive(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.exp.Len() > 0 {
+		top := s.exp[0]
+		if top.expireAt.After(now) {
+			return
+		}
+		heap.Pop(&s.exp)
+
+		// Set/Expire push a new heap entry without removing the old
+		// one, so the heap can hold stale entries for a key that was
+		// overwritten since this entry was pushed. Only delete if the
+		// live entry's expiry still matches the one we just popped.
+		if e, ok := s.data[top.key]; ok && e.expireAt.Equal(top.expireAt) {
+			delete(s.data, top.key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetExpiresAfterPX(t *testing.T) {
+	s := New()
+	s.Set("foo", []byte("bar"), time.Now().Add(50*time.Millisecond))
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ok := s.Get("foo"); ok {
+		t.Fatalf("expected foo to have expired after PX 50, but Get still found a value")
+	}
+}
+
+func TestExpireActiveReclaimsUnreadKeys(t *testing.T) {
+	s := New()
+	s.Set("foo", []byte("bar"), time.Now().Add(50*time.Millisecond))
+
+	time.Sleep(100 * time.Millisecond)
+	s.ExpireActive(time.Now())
+
+	if len(s.data) != 0 {
+		t.Fatalf("expected ExpireActive to remove the expired key, data = %v", s.data)
+	}
+}
+
+func TestPersistClearsExpiry(t *testing.T) {
+	s := New()
+	s.Set("foo", []byte("bar"), time.Now().Add(50*time.Millisecond))
+
+	if !s.Persist("foo") {
+		t.Fatalf("expected Persist to report the key had an expiry")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ok := s.Get("foo"); !ok {
+		t.Fatalf("expected foo to survive past its original expiry after Persist")
+	}
+}